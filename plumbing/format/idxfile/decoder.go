@@ -0,0 +1,239 @@
+package idxfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the idx file
+	// declares a version other than the ones this package understands.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrMalformedIdxFile is returned by Decode when the idx file does not
+	// start with the expected magic bytes, or is otherwise inconsistent.
+	ErrMalformedIdxFile = errors.New("malformed IDX file")
+	// ErrHashWidthMismatch is returned by MemoryIndex's plumbing.Hash-based
+	// lookup methods (Contains, FindOffset, FindCRC32) when called against
+	// a SHA-256 index, since plumbing.Hash is fixed at the SHA-1 width and
+	// cannot represent the hashes such an index stores. Use the
+	// corresponding *Bytes method instead.
+	ErrHashWidthMismatch = errors.New("idxfile: hash width does not match index's hash algorithm")
+)
+
+// Decoder reads and decodes idx files from an input stream. It handles
+// version 2 (always SHA-1) and version 3, which declares its own hash
+// algorithm and width in the header, so no extra configuration is
+// needed to decode a SHA-256 index. Version 1 (the original,
+// fanout-only format with no magic header) is not supported.
+type Decoder struct {
+	*bufio.Reader
+}
+
+// NewDecoder builds a new idx file decoder, using the given io.Reader as
+// its source.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads from the decoder's reader and decodes it as an idx file into
+// the MemoryIndex pointed to by idx.
+func (d *Decoder) Decode(idx *MemoryIndex) error {
+	if err := d.validateHeader(); err != nil {
+		return err
+	}
+
+	version, err := d.readVersion()
+	if err != nil {
+		return err
+	}
+	idx.Version = version
+
+	hashSize := HashAlgorithmSHA1.Size()
+	switch {
+	case version == VersionSupported:
+		// version 2: always SHA-1, no further header fields.
+	case version == VersionSupportedV3:
+		algo, size, err := d.readHashHeader()
+		if err != nil {
+			return err
+		}
+		idx.HashAlgorithm = algo
+		hashSize = size
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	if err := d.readFanout(idx); err != nil {
+		return err
+	}
+
+	if err := d.readObjectNames(idx, hashSize); err != nil {
+		return err
+	}
+
+	if err := d.readCRC32(idx); err != nil {
+		return err
+	}
+
+	if err := d.readOffsets(idx); err != nil {
+		return err
+	}
+
+	if idx.PackfileChecksum, err = d.readHash(hashSize); err != nil {
+		return err
+	}
+
+	if idx.IdxChecksum, err = d.readHash(hashSize); err != nil && err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Decoder) validateHeader() error {
+	h := make([]byte, 4)
+	if _, err := io.ReadFull(d, h); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(h, idxHeader) {
+		return ErrMalformedIdxFile
+	}
+
+	return nil
+}
+
+func (d *Decoder) readVersion() (uint32, error) {
+	var version uint32
+	if err := binary.Read(d, binary.BigEndian, &version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// readHashHeader reads the version-3-only byte pair that identifies the
+// hash algorithm and its width, as described on HashAlgorithm.
+func (d *Decoder) readHashHeader() (HashAlgorithm, int, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(d, header); err != nil {
+		return 0, 0, err
+	}
+
+	algo := HashAlgorithm(header[0])
+	if algo != HashAlgorithmSHA1 && algo != HashAlgorithmSHA256 {
+		return 0, 0, ErrMalformedIdxFile
+	}
+
+	size := int(header[1])
+	if size != algo.Size() {
+		return 0, 0, ErrMalformedIdxFile
+	}
+
+	return algo, size, nil
+}
+
+func (d *Decoder) readFanout(idx *MemoryIndex) error {
+	for k := 0; k < 256; k++ {
+		if err := binary.Read(d, binary.BigEndian, &idx.Fanout[k]); err != nil {
+			return err
+		}
+
+		idx.FanoutMapping[k] = noMapping
+	}
+
+	return nil
+}
+
+func (d *Decoder) readObjectNames(idx *MemoryIndex, hashSize int) error {
+	idx.Names = make([][]byte, 0, 256)
+	idx.Offset32 = make([][]byte, 0, 256)
+	idx.CRC32 = make([][]byte, 0, 256)
+
+	last := uint32(0)
+	bucket := 0
+	for k := 0; k < 256; k++ {
+		n := idx.Fanout[k] - last
+		last = idx.Fanout[k]
+		if n == 0 {
+			continue
+		}
+
+		buf := make([]byte, int(n)*hashSize)
+		if _, err := io.ReadFull(d, buf); err != nil {
+			return err
+		}
+
+		idx.Names = append(idx.Names, buf)
+		idx.Offset32 = append(idx.Offset32, nil)
+		idx.CRC32 = append(idx.CRC32, nil)
+		idx.FanoutMapping[k] = bucket
+		bucket++
+	}
+
+	return nil
+}
+
+func (d *Decoder) readCRC32(idx *MemoryIndex) error {
+	for k := 0; k < 256; k++ {
+		bucket := idx.FanoutMapping[k]
+		if bucket == noMapping {
+			continue
+		}
+
+		count := len(idx.Names[bucket]) / idx.hashSize()
+		buf := make([]byte, count*4)
+		if _, err := io.ReadFull(d, buf); err != nil {
+			return err
+		}
+
+		idx.CRC32[bucket] = buf
+	}
+
+	return nil
+}
+
+func (d *Decoder) readOffsets(idx *MemoryIndex) error {
+	largeOffsets := 0
+	for k := 0; k < 256; k++ {
+		bucket := idx.FanoutMapping[k]
+		if bucket == noMapping {
+			continue
+		}
+
+		count := len(idx.Names[bucket]) / idx.hashSize()
+		buf := make([]byte, count*4)
+		if _, err := io.ReadFull(d, buf); err != nil {
+			return err
+		}
+
+		for i := 0; i < count; i++ {
+			if uint32From4Bytes(buf[i*4:i*4+4])&largeOffsetFlag != 0 {
+				largeOffsets++
+			}
+		}
+
+		idx.Offset32[bucket] = buf
+	}
+
+	if largeOffsets > 0 {
+		idx.Offset64 = make([]byte, largeOffsets*8)
+		if _, err := io.ReadFull(d, idx.Offset64); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Decoder) readHash(size int) ([]byte, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(d, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,20 @@
+package idxfile_test
+
+import (
+	"bytes"
+
+	. "github.com/grahambrooks/go-git/v5/plumbing/format/idxfile"
+)
+
+// TestDecodeRejectsInvalidHashAlgorithm builds a version 3 header with an
+// algorithm byte that isn't SHA-1 or SHA-256, paired with a size byte (20)
+// that would otherwise slip past the size != algo.Size() check, since
+// HashAlgorithm.Size() falls back to the SHA-1 width for any unrecognized
+// algorithm.
+func (s *IdxfileSuite) TestDecodeRejectsInvalidHashAlgorithm() {
+	raw := []byte{0xff, 't', 'O', 'c', 0, 0, 0, 3, 3, 20}
+
+	idx := new(MemoryIndex)
+	err := NewDecoder(bytes.NewBuffer(raw)).Decode(idx)
+	s.ErrorIs(err, ErrMalformedIdxFile)
+}
@@ -0,0 +1,152 @@
+package idxfile
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Encoder writes MemoryIndex structs to an output stream.
+type Encoder struct {
+	io.Writer
+}
+
+// NewEncoder builds a new idx file encoder, using the given io.Writer as
+// its destination. The version and format it writes are driven by the
+// MemoryIndex passed to Encode: an index with Version set is written at
+// that version; with no Version set, a SHA-1 index produces a version 2
+// idx file and any other HashAlgorithm produces version 3.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes idx to the encoder's writer. When idx.Version is unset it
+// auto-selects version 2 for SHA-1 indexes and version 3 for any other
+// HashAlgorithm, and stores that choice in idx.Version. It returns the
+// number of bytes written.
+func (e *Encoder) Encode(idx *MemoryIndex) (int, error) {
+	if idx.Version == 0 {
+		if idx.HashAlgorithm == 0 || idx.HashAlgorithm == HashAlgorithmSHA1 {
+			idx.Version = VersionSupported
+		} else {
+			idx.Version = VersionSupportedV3
+		}
+	}
+
+	flow := []func(*MemoryIndex) (int, error){
+		e.encodeHeader,
+		e.encodeFanout,
+		e.encodeHashes,
+		e.encodeCRC32,
+		e.encodeOffsets,
+		e.encodeChecksums,
+	}
+
+	sz := 0
+	for _, f := range flow {
+		i, err := f(idx)
+		sz += i
+
+		if err != nil {
+			return sz, err
+		}
+	}
+
+	return sz, nil
+}
+
+func (e *Encoder) encodeHeader(idx *MemoryIndex) (int, error) {
+	c, err := e.Write(idxHeader)
+	if err != nil {
+		return c, err
+	}
+
+	if err := binary.Write(e, binary.BigEndian, idx.Version); err != nil {
+		return c, err
+	}
+	c += 4
+
+	if idx.Version < VersionSupportedV3 {
+		return c, nil
+	}
+
+	algo := idx.HashAlgorithm
+	if algo == 0 {
+		algo = HashAlgorithmSHA1
+	}
+
+	n, err := e.Write([]byte{byte(algo), byte(algo.Size())})
+	return c + n, err
+}
+
+func (e *Encoder) encodeFanout(idx *MemoryIndex) (int, error) {
+	for _, f := range idx.Fanout {
+		if err := binary.Write(e, binary.BigEndian, f); err != nil {
+			return 0, err
+		}
+	}
+
+	return 256 * 4, nil
+}
+
+func (e *Encoder) encodeHashes(idx *MemoryIndex) (size int, err error) {
+	for _, b := range idx.Names {
+		i, err := e.Write(b)
+		size += i
+
+		if err != nil {
+			return size, err
+		}
+	}
+
+	return
+}
+
+func (e *Encoder) encodeCRC32(idx *MemoryIndex) (size int, err error) {
+	for _, b := range idx.CRC32 {
+		i, err := e.Write(b)
+		size += i
+
+		if err != nil {
+			return size, err
+		}
+	}
+
+	return
+}
+
+func (e *Encoder) encodeOffsets(idx *MemoryIndex) (size int, err error) {
+	for _, b := range idx.Offset32 {
+		i, err := e.Write(b)
+		size += i
+
+		if err != nil {
+			return size, err
+		}
+	}
+
+	i, err := e.Write(idx.Offset64)
+	size += i
+
+	return
+}
+
+// encodeChecksums writes both trailing checksums. A checksum left empty,
+// as Writer leaves them, is written as zeros at the index's hash width so
+// the result is still a complete idx file that Decoder can read back.
+func (e *Encoder) encodeChecksums(idx *MemoryIndex) (int, error) {
+	packfileChecksum, idxChecksum := idx.PackfileChecksum, idx.IdxChecksum
+	if len(packfileChecksum) == 0 {
+		packfileChecksum = make([]byte, idx.hashSize())
+	}
+	if len(idxChecksum) == 0 {
+		idxChecksum = make([]byte, idx.hashSize())
+	}
+
+	c, err := e.Write(packfileChecksum)
+	if err != nil {
+		return c, err
+	}
+
+	n, err := e.Write(idxChecksum)
+	return c + n, err
+}
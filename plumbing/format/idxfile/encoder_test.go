@@ -28,3 +28,52 @@ func (s *IdxfileSuite) TestDecodeEncode() {
 		s.Equal(expected, result.Bytes())
 	}
 }
+
+// TestDecodeEncodeV3 is a KNOWN STOPGAP, not the fixture-based
+// TestDecodeEncode-style round trip version 3 should eventually get:
+// go-git-fixtures has no SHA-256 packfile/idx pairs yet, so there is
+// nothing to read a real version 3 index from. Until such a fixture
+// exists, this hand-builds a MemoryIndex instead, which round-trips the
+// encode/decode logic but does not confirm byte-for-byte compatibility
+// with a real `git index-pack --object-format=sha256` output the way
+// TestDecodeEncode does for SHA-1. Replace this with a fixture-backed
+// test once a SHA-256 fixture is available.
+func (s *IdxfileSuite) TestDecodeEncodeV3() {
+	nameA := bytes.Repeat([]byte{0x01}, 32)
+	nameB := bytes.Repeat([]byte{0x02}, 32)
+
+	idx := &MemoryIndex{
+		Version:          VersionSupportedV3,
+		HashAlgorithm:    HashAlgorithmSHA256,
+		Names:            [][]byte{append(append([]byte{}, nameA...), nameB...)},
+		CRC32:            [][]byte{{0, 0, 0, 1, 0, 0, 0, 2}},
+		Offset32:         [][]byte{{0, 0, 0, 10, 0, 0, 0, 20}},
+		PackfileChecksum: bytes.Repeat([]byte{0xaa}, 32),
+		IdxChecksum:      bytes.Repeat([]byte{0xbb}, 32),
+	}
+	idx.Fanout[0x01] = 1
+	for k := 2; k < 256; k++ {
+		idx.Fanout[k] = 2
+	}
+	idx.FanoutMapping[0x01] = 0
+	idx.FanoutMapping[0x02] = 0
+	for k := 0; k < 256; k++ {
+		if k != 0x01 && k != 0x02 {
+			idx.FanoutMapping[k] = -1
+		}
+	}
+
+	encoded := bytes.NewBuffer(nil)
+	e := NewEncoder(encoded)
+	_, err := e.Encode(idx)
+	s.NoError(err)
+
+	decoded := new(MemoryIndex)
+	d := NewDecoder(bytes.NewBuffer(encoded.Bytes()))
+	s.NoError(d.Decode(decoded))
+
+	s.Equal(uint32(VersionSupportedV3), decoded.Version)
+	s.Equal(HashAlgorithmSHA256, decoded.HashAlgorithm)
+	s.Equal(idx.PackfileChecksum, decoded.PackfileChecksum)
+	s.Equal(idx.IdxChecksum, decoded.IdxChecksum)
+}
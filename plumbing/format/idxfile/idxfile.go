@@ -0,0 +1,494 @@
+// Package idxfile implements encoding and decoding of packfile idx files.
+package idxfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+)
+
+const (
+	// VersionSupported is the idx version produced by Encoder and
+	// understood by Decoder for SHA-1 repositories. Version 3, which
+	// supports hash algorithms other than SHA-1, is also understood; see
+	// HashAlgorithm.
+	VersionSupported = 2
+
+	// VersionSupportedV3 is the idx version that carries a HashAlgorithm
+	// byte and a variable-width hash, as used by SHA-256 repositories.
+	VersionSupportedV3 = 3
+
+	noMapping = -1
+
+	// largeOffsetFlag marks a 32-bit Offset32 entry as an index into
+	// Offset64 rather than a literal offset, for objects beyond the 2GiB
+	// packfile offset boundary.
+	largeOffsetFlag = uint32(1) << 31
+)
+
+var idxHeader = []byte{255, 't', 'O', 'c'}
+
+// HashAlgorithm identifies the object hash used by an idx file. It is only
+// meaningful for version 3 idx files; version 2 files are always SHA-1.
+type HashAlgorithm byte
+
+const (
+	// HashAlgorithmSHA1 is Git's original, 20-byte object hash.
+	HashAlgorithmSHA1 HashAlgorithm = 1
+	// HashAlgorithmSHA256 is Git's 32-byte object hash, used by
+	// SHA-256 repositories.
+	HashAlgorithmSHA256 HashAlgorithm = 2
+)
+
+// Size returns the width, in bytes, of hashes produced by this algorithm.
+// It defaults to the SHA-1 width for the zero value, so that version 2
+// idx files (which have no HashAlgorithm byte) keep working unchanged.
+func (h HashAlgorithm) Size() int {
+	if h == HashAlgorithmSHA256 {
+		return 32
+	}
+
+	return 20
+}
+
+func (h HashAlgorithm) String() string {
+	switch h {
+	case HashAlgorithmSHA256:
+		return "sha256"
+	default:
+		return "sha1"
+	}
+}
+
+// Index represents an index of a packfile.
+type Index interface {
+	// Contains checks whether a given hash is in the index.
+	Contains(h plumbing.Hash) (bool, error)
+	// FindOffset finds the offset in the packfile for the object with
+	// the given hash.
+	FindOffset(h plumbing.Hash) (int64, error)
+	// FindCRC32 finds the CRC32 of the object with the given hash.
+	FindCRC32(h plumbing.Hash) (uint32, error)
+	// FindHash finds the hash for the object with the given offset.
+	FindHash(o int64) (plumbing.Hash, error)
+	// Count returns the number of entries in the index.
+	Count() (int64, error)
+	// Entries returns an iterator to retrieve all index entries.
+	Entries() (EntryIter, error)
+	// EntriesByOffset returns an iterator to retrieve all index entries
+	// ordered by offset.
+	EntriesByOffset() (EntryIter, error)
+}
+
+// MemoryIndex is the in memory representation of an idx file.
+type MemoryIndex struct {
+	Version uint32
+
+	// HashAlgorithm is the object hash used by this index. It is only
+	// populated (and only ever non-zero) for version 3 idx files; a zero
+	// value means SHA-1, matching the behaviour of version 1 and 2 files.
+	HashAlgorithm HashAlgorithm
+
+	Fanout [256]uint32
+	// FanoutMapping maps the position in the fanout table to the position
+	// in the Names, Offset32 and CRC32 slices. This improves the memory
+	// usage by not needing an array with unnecessary empty slots.
+	FanoutMapping [256]int
+
+	Names    [][]byte
+	Offset32 [][]byte
+	CRC32    [][]byte
+	Offset64 []byte
+
+	PackfileChecksum []byte
+	IdxChecksum      []byte
+
+	offsetHash  map[int64]plumbing.Hash
+	objectCount int
+}
+
+// hashSize returns the width, in bytes, of the object hashes stored in this
+// index: 20 for SHA-1 (version 1 and 2, and version 3 with
+// HashAlgorithmSHA1), 32 for version 3 with HashAlgorithmSHA256.
+func (idx *MemoryIndex) hashSize() int {
+	if idx.Version < VersionSupportedV3 {
+		return HashAlgorithmSHA1.Size()
+	}
+
+	return idx.HashAlgorithm.Size()
+}
+
+// findHashIndexBytes looks up h, a raw object id at this index's own hash
+// width, against this index's name table.
+func (idx *MemoryIndex) findHashIndexBytes(h []byte) (int, bool) {
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return 0, false
+	}
+
+	size := idx.hashSize()
+
+	data := idx.Names[k]
+	high := uint64(len(data)) / uint64(size)
+	if high == 0 {
+		return 0, false
+	}
+
+	low := uint64(0)
+	for low < high {
+		mid := (low + high) >> 1
+		offset := mid * uint64(size)
+		cmp := bytes.Compare(data[offset:offset+uint64(size)], h[:size])
+
+		if cmp < 0 {
+			low = mid + 1
+		} else if cmp > 0 {
+			high = mid
+		} else {
+			return int(mid), true
+		}
+	}
+
+	return 0, false
+}
+
+// findHashIndex looks up h, a plumbing.Hash, against this index's name
+// table. plumbing.Hash is fixed at the SHA-1 width, so this only works
+// for a SHA-1 (version 1, 2, or version-3-with-SHA-1) index; callers must
+// confirm idx.hashSize() == len(h) first, since findHashIndexBytes slices
+// h to the index's hash width and a shorter h would be sliced past its end.
+func (idx *MemoryIndex) findHashIndex(h plumbing.Hash) (int, bool) {
+	return idx.findHashIndexBytes(h[:])
+}
+
+// ContainsBytes reports whether the given raw object id, at whatever
+// width this index's hash algorithm uses, is present in this index.
+// Unlike Contains, it works for both SHA-1 and SHA-256 indexes, since
+// plumbing.Hash cannot hold a SHA-256 id.
+func (idx *MemoryIndex) ContainsBytes(h []byte) bool {
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return false
+	}
+
+	_, ok := idx.findHashIndexBytes(h)
+	return ok
+}
+
+// Contains implements the Index interface. It only supports SHA-1
+// indexes (version 1, 2, or version-3-with-SHA-1); called against a
+// SHA-256 index it returns ErrHashWidthMismatch rather than a false
+// negative, since plumbing.Hash cannot hold a SHA-256 id. Use
+// ContainsBytes for a SHA-256 index.
+func (idx *MemoryIndex) Contains(h plumbing.Hash) (bool, error) {
+	if idx.hashSize() != len(h) {
+		return false, ErrHashWidthMismatch
+	}
+
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return false, nil
+	}
+
+	_, ok := idx.findHashIndex(h)
+	return ok, nil
+}
+
+// FindOffsetBytes finds the packfile offset of the given raw object id,
+// at whatever width this index's hash algorithm uses. Unlike FindOffset,
+// it works for both SHA-1 and SHA-256 indexes, since plumbing.Hash cannot
+// hold a SHA-256 id.
+func (idx *MemoryIndex) FindOffsetBytes(h []byte) (int64, error) {
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	i, ok := idx.findHashIndexBytes(h)
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	return idx.getOffset(k, i)
+}
+
+// FindOffset implements the Index interface. It only supports SHA-1
+// indexes (version 1, 2, or version-3-with-SHA-1); called against a
+// SHA-256 index it returns ErrHashWidthMismatch rather than a false
+// ErrObjectNotFound, since plumbing.Hash cannot hold a SHA-256 id. Use
+// FindOffsetBytes for a SHA-256 index.
+func (idx *MemoryIndex) FindOffset(h plumbing.Hash) (int64, error) {
+	if idx.hashSize() != len(h) {
+		return 0, ErrHashWidthMismatch
+	}
+
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	i, ok := idx.findHashIndex(h)
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	return idx.getOffset(k, i)
+}
+
+func (idx *MemoryIndex) getOffset(firstLevel, secondLevel int) (int64, error) {
+	offset := secondLevel * 4
+	buf := idx.Offset32[firstLevel][offset : offset+4]
+
+	ofs := uint32From4Bytes(buf)
+	if ofs&largeOffsetFlag == 0 {
+		return int64(ofs), nil
+	}
+
+	offset = int(ofs&^largeOffsetFlag) * 8
+	if offset+8 > len(idx.Offset64) {
+		return 0, fmt.Errorf("invalid large offset index: %d", offset)
+	}
+
+	return int64(uint64From8Bytes(idx.Offset64[offset : offset+8])), nil
+}
+
+func uint32From4Bytes(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func uint64From8Bytes(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// FindCRC32Bytes finds the CRC32 of the object with the given raw object
+// id, at whatever width this index's hash algorithm uses. Unlike
+// FindCRC32, it works for both SHA-1 and SHA-256 indexes, since
+// plumbing.Hash cannot hold a SHA-256 id.
+func (idx *MemoryIndex) FindCRC32Bytes(h []byte) (uint32, error) {
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	i, ok := idx.findHashIndexBytes(h)
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	offset := i * 4
+	return uint32From4Bytes(idx.CRC32[k][offset : offset+4]), nil
+}
+
+// FindCRC32 implements the Index interface. It only supports SHA-1
+// indexes (version 1, 2, or version-3-with-SHA-1); called against a
+// SHA-256 index it returns ErrHashWidthMismatch rather than a false
+// ErrObjectNotFound, since plumbing.Hash cannot hold a SHA-256 id. Use
+// FindCRC32Bytes for a SHA-256 index.
+func (idx *MemoryIndex) FindCRC32(h plumbing.Hash) (uint32, error) {
+	if idx.hashSize() != len(h) {
+		return 0, ErrHashWidthMismatch
+	}
+
+	k := idx.FanoutMapping[h[0]]
+	if k == noMapping {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	i, ok := idx.findHashIndex(h)
+	if !ok {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	offset := i * 4
+	return uint32From4Bytes(idx.CRC32[k][offset : offset+4]), nil
+}
+
+// FindHash implements the Index interface.
+func (idx *MemoryIndex) FindHash(o int64) (plumbing.Hash, error) {
+	if idx.offsetHash == nil {
+		if err := idx.genOffsetHash(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	hash, ok := idx.offsetHash[o]
+	if !ok {
+		return plumbing.ZeroHash, plumbing.ErrObjectNotFound
+	}
+
+	return hash, nil
+}
+
+func (idx *MemoryIndex) genOffsetHash() error {
+	count, err := idx.Count()
+	if err != nil {
+		return err
+	}
+
+	idx.offsetHash = make(map[int64]plumbing.Hash, count)
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return err
+	}
+
+	for {
+		e, err := iter.Next()
+		if err != nil {
+			break
+		}
+
+		idx.offsetHash[int64(e.Offset)] = e.Hash
+	}
+
+	return nil
+}
+
+// Count implements the Index interface.
+func (idx *MemoryIndex) Count() (int64, error) {
+	if idx.objectCount == 0 {
+		idx.calculateObjectCount()
+	}
+
+	return int64(idx.objectCount), nil
+}
+
+func (idx *MemoryIndex) calculateObjectCount() {
+	if idx.Fanout[255] == 0 {
+		return
+	}
+
+	idx.objectCount = int(idx.Fanout[255])
+}
+
+// Entries implements the Index interface.
+func (idx *MemoryIndex) Entries() (EntryIter, error) {
+	return &idxfileEntryIter{idx, 0, 0, 0}, nil
+}
+
+// EntriesByOffset implements the Index interface.
+func (idx *MemoryIndex) EntriesByOffset() (EntryIter, error) {
+	count, err := idx.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(entriesByOffset, 0, count)
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		e, err := iter.Next()
+		if err != nil {
+			break
+		}
+
+		entries = append(entries, e)
+	}
+
+	sortEntriesByOffset(entries)
+
+	return &sliceEntryIter{entries: entries}, nil
+}
+
+// Entry is the in memory representation of an object in a packfile idx.
+type Entry struct {
+	// Hash is the object id truncated (or, for SHA-1, exactly sized) to
+	// plumbing.Hash's fixed 20-byte width. For a SHA-256 index, use
+	// RawHash to get the full id.
+	Hash plumbing.Hash
+	// RawHash is the object id at this index's native width: 20 bytes for
+	// SHA-1, 32 for SHA-256.
+	RawHash []byte
+	CRC32   uint32
+	Offset  uint64
+}
+
+// EntryIter is an iterator that will return the entries in a packfile
+// index.
+type EntryIter interface {
+	// Next returns the next entry in the packfile index.
+	Next() (*Entry, error)
+}
+
+type idxfileEntryIter struct {
+	idx          *MemoryIndex
+	total        int
+	totalObjects int
+	currentFirst int
+}
+
+func (i *idxfileEntryIter) Next() (*Entry, error) {
+	if i.totalObjects == 0 {
+		for i.currentFirst < 256 {
+			if i.idx.FanoutMapping[i.currentFirst] != noMapping {
+				break
+			}
+			i.currentFirst++
+		}
+
+		if i.currentFirst >= 256 {
+			return nil, io.EOF
+		}
+	}
+
+	pos := i.idx.FanoutMapping[i.currentFirst]
+	size := i.idx.hashSize()
+	data := i.idx.Names[pos]
+	count := len(data) / size
+
+	if i.totalObjects >= count {
+		i.currentFirst++
+		i.totalObjects = 0
+		return i.Next()
+	}
+
+	rawHash := data[i.totalObjects*size : (i.totalObjects+1)*size]
+
+	var hash plumbing.Hash
+	copy(hash[:], rawHash)
+
+	crc := uint32From4Bytes(i.idx.CRC32[pos][i.totalObjects*4 : i.totalObjects*4+4])
+	offset, err := i.idx.getOffset(pos, i.totalObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	i.totalObjects++
+	i.total++
+
+	return &Entry{Hash: hash, RawHash: rawHash, CRC32: crc, Offset: uint64(offset)}, nil
+}
+
+type entriesByOffset []*Entry
+
+func sortEntriesByOffset(e entriesByOffset) {
+	for i := 1; i < len(e); i++ {
+		for j := i; j > 0 && e[j-1].Offset > e[j].Offset; j-- {
+			e[j-1], e[j] = e[j], e[j-1]
+		}
+	}
+}
+
+type sliceEntryIter struct {
+	entries entriesByOffset
+	pos     int
+}
+
+func (i *sliceEntryIter) Next() (*Entry, error) {
+	if i.pos >= len(i.entries) {
+		return nil, io.EOF
+	}
+
+	e := i.entries[i.pos]
+	i.pos++
+	return e, nil
+}
@@ -0,0 +1,15 @@
+package idxfile_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IdxfileSuite struct {
+	suite.Suite
+}
+
+func TestIdxfileSuite(t *testing.T) {
+	suite.Run(t, new(IdxfileSuite))
+}
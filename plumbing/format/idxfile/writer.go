@@ -0,0 +1,107 @@
+package idxfile
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Writer is used to generate indexes for packfiles. Objects are registered
+// one at a time with Add, and Index produces a MemoryIndex from them. It
+// does not implement packfile.Observer, and it does not record the
+// packfile's checksum: the MemoryIndex it returns has no PackfileChecksum
+// or IdxChecksum.
+type Writer struct {
+	// HashAlgorithm selects the object hash used by the resulting index.
+	// The zero value and HashAlgorithmSHA1 both produce a version 2 index;
+	// any other value produces a version 3 index carrying that algorithm.
+	HashAlgorithm HashAlgorithm
+
+	objects []writerObject
+}
+
+type writerObject struct {
+	// hash holds the object id at whatever width HashAlgorithm uses. It is
+	// a raw byte slice, rather than a plumbing.Hash, because plumbing.Hash
+	// is sized for SHA-1 and cannot hold a SHA-256 id.
+	hash   []byte
+	crc32  uint32
+	offset int64
+}
+
+// Add registers one object, with its hash, CRC32 checksum, and offset in
+// the packfile, to be included in the index produced by Index. hash must
+// be HashAlgorithm.Size() bytes long.
+func (w *Writer) Add(hash []byte, crc32 uint32, offset int64) {
+	w.objects = append(w.objects, writerObject{hash, crc32, offset})
+}
+
+// Index returns a MemoryIndex built from the objects added so far, sorted
+// by hash as required by the idx format.
+func (w *Writer) Index() (*MemoryIndex, error) {
+	hashSize := w.hashAlgorithm().Size()
+
+	sort.Slice(w.objects, func(i, j int) bool {
+		return bytes.Compare(w.objects[i].hash, w.objects[j].hash) < 0
+	})
+
+	idx := &MemoryIndex{}
+	if w.HashAlgorithm == 0 || w.HashAlgorithm == HashAlgorithmSHA1 {
+		idx.Version = VersionSupported
+	} else {
+		idx.Version = VersionSupportedV3
+		idx.HashAlgorithm = w.HashAlgorithm
+	}
+
+	buckets := make(map[byte][]writerObject)
+	for _, o := range w.objects {
+		buckets[o.hash[0]] = append(buckets[o.hash[0]], o)
+	}
+
+	var running uint32
+	for k := 0; k < 256; k++ {
+		objs, ok := buckets[byte(k)]
+		if !ok {
+			idx.FanoutMapping[k] = noMapping
+			idx.Fanout[k] = running
+			continue
+		}
+
+		names := make([]byte, 0, len(objs)*hashSize)
+		crcs := make([]byte, 0, len(objs)*4)
+		offsets := make([]byte, 0, len(objs)*4)
+
+		for _, o := range objs {
+			names = append(names, o.hash...)
+			crcs = append(crcs, byte(o.crc32>>24), byte(o.crc32>>16), byte(o.crc32>>8), byte(o.crc32))
+
+			var offset uint32
+			if o.offset <= 0x7fffffff {
+				offset = uint32(o.offset)
+			} else {
+				offset = largeOffsetFlag | uint32(len(idx.Offset64)/8)
+				idx.Offset64 = append(idx.Offset64,
+					byte(o.offset>>56), byte(o.offset>>48), byte(o.offset>>40), byte(o.offset>>32),
+					byte(o.offset>>24), byte(o.offset>>16), byte(o.offset>>8), byte(o.offset))
+			}
+			offsets = append(offsets, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset))
+		}
+
+		idx.FanoutMapping[k] = len(idx.Names)
+		idx.Names = append(idx.Names, names)
+		idx.CRC32 = append(idx.CRC32, crcs)
+		idx.Offset32 = append(idx.Offset32, offsets)
+
+		running += uint32(len(objs))
+		idx.Fanout[k] = running
+	}
+
+	return idx, nil
+}
+
+func (w *Writer) hashAlgorithm() HashAlgorithm {
+	if w.HashAlgorithm == 0 {
+		return HashAlgorithmSHA1
+	}
+
+	return w.HashAlgorithm
+}
@@ -0,0 +1,78 @@
+package idxfile_test
+
+import (
+	"bytes"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	. "github.com/grahambrooks/go-git/v5/plumbing/format/idxfile"
+)
+
+func (s *IdxfileSuite) TestWriterSHA256() {
+	w := &Writer{HashAlgorithm: HashAlgorithmSHA256}
+
+	hashes := [][]byte{
+		bytes.Repeat([]byte{0x01}, 32),
+		bytes.Repeat([]byte{0x02}, 32),
+	}
+	for i, h := range hashes {
+		w.Add(h, uint32(i+1), int64(i*10))
+	}
+
+	idx, err := w.Index()
+	s.NoError(err)
+	s.Equal(uint32(VersionSupportedV3), idx.Version)
+	s.Equal(HashAlgorithmSHA256, idx.HashAlgorithm)
+
+	encoded := bytes.NewBuffer(nil)
+	_, err = NewEncoder(encoded).Encode(idx)
+	s.NoError(err)
+
+	decoded := new(MemoryIndex)
+	s.NoError(NewDecoder(bytes.NewBuffer(encoded.Bytes())).Decode(decoded))
+	s.Equal(idx.HashAlgorithm, decoded.HashAlgorithm)
+
+	s.True(decoded.ContainsBytes(hashes[0]))
+	offset, err := decoded.FindOffsetBytes(hashes[1])
+	s.NoError(err)
+	s.Equal(int64(10), offset)
+
+	_, err = decoded.Contains(plumbing.ZeroHash)
+	s.ErrorIs(err, ErrHashWidthMismatch, "plumbing.Hash is SHA-1 width and cannot address a SHA-256 index")
+	_, err = decoded.FindOffset(plumbing.ZeroHash)
+	s.ErrorIs(err, ErrHashWidthMismatch)
+	_, err = decoded.FindCRC32(plumbing.ZeroHash)
+	s.ErrorIs(err, ErrHashWidthMismatch)
+}
+
+// TestWriterLargeOffset checks that an object beyond the 2GiB packfile
+// offset boundary round-trips through Offset64, rather than being
+// silently truncated into a wrong 32-bit offset.
+func (s *IdxfileSuite) TestWriterLargeOffset() {
+	w := &Writer{}
+
+	const largeOffset = int64(0x123456789)
+
+	hashes := [][]byte{
+		bytes.Repeat([]byte{0x01}, 20),
+		bytes.Repeat([]byte{0x02}, 20),
+	}
+	w.Add(hashes[0], 1, 10)
+	w.Add(hashes[1], 2, largeOffset)
+
+	idx, err := w.Index()
+	s.NoError(err)
+	s.NotEmpty(idx.Offset64)
+
+	encoded := bytes.NewBuffer(nil)
+	_, err = NewEncoder(encoded).Encode(idx)
+	s.NoError(err)
+
+	decoded := new(MemoryIndex)
+	s.NoError(NewDecoder(bytes.NewBuffer(encoded.Bytes())).Decode(decoded))
+
+	var h plumbing.Hash
+	copy(h[:], hashes[1])
+	offset, err := decoded.FindOffset(h)
+	s.NoError(err)
+	s.Equal(largeOffset, offset)
+}
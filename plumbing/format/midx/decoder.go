@@ -0,0 +1,206 @@
+package midx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrMalformedMidxFile is returned by Decode when the input does not
+	// look like a MIDX file, or is truncated.
+	ErrMalformedMidxFile = errors.New("malformed multi-pack-index file")
+	// ErrUnsupportedVersion is returned by Decode when the MIDX declares a
+	// format version this package does not understand.
+	ErrUnsupportedVersion = errors.New("unsupported multi-pack-index version")
+)
+
+type chunkTableEntry struct {
+	id     [4]byte
+	offset uint64
+}
+
+// Decoder reads and decodes MIDX files from an input stream.
+type Decoder struct {
+	r io.ReadSeeker
+}
+
+// NewDecoder builds a new MIDX decoder. r must support seeking, since the
+// chunk lookup table gives chunks as absolute offsets into the file.
+func NewDecoder(r io.ReadSeeker) *Decoder {
+	return &Decoder{r}
+}
+
+// Decode reads a full MIDX file and returns its in-memory representation.
+func (d *Decoder) Decode() (*MemoryIndex, error) {
+	fileSize, err := d.r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(header[0:4], []byte(Magic)) {
+		return nil, ErrMalformedMidxFile
+	}
+
+	version := header[4]
+	if version != VersionSupported {
+		return nil, ErrUnsupportedVersion
+	}
+
+	idx := &MemoryIndex{HashVersion: header[5]}
+	numChunks := int(header[6])
+	// header[7] is the number of base MIDX files; chained MIDX files are
+	// not supported.
+	numPacks := binary.BigEndian.Uint32(header[8:12])
+
+	table := make([]chunkTableEntry, numChunks+1)
+	for i := range table {
+		entry := make([]byte, 12)
+		if _, err := io.ReadFull(d.r, entry); err != nil {
+			return nil, err
+		}
+
+		copy(table[i].id[:], entry[0:4])
+		table[i].offset = binary.BigEndian.Uint64(entry[4:12])
+
+		if table[i].offset > uint64(fileSize) {
+			return nil, ErrMalformedMidxFile
+		}
+	}
+
+	chunks := make(map[[4]byte][]byte)
+	for i := 0; i < numChunks; i++ {
+		start := table[i].offset
+		end := table[i+1].offset
+
+		if end < start {
+			return nil, ErrMalformedMidxFile
+		}
+
+		buf := make([]byte, end-start)
+		if _, err := d.r.Seek(int64(start), io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(d.r, buf); err != nil {
+			return nil, err
+		}
+
+		chunks[table[i].id] = buf
+	}
+
+	if err := decodePackNames(idx, chunks[chunkIDPackNames], int(numPacks)); err != nil {
+		return nil, err
+	}
+
+	if err := decodeFanout(idx, chunks[chunkIDOIDFanout]); err != nil {
+		return nil, err
+	}
+
+	size := idx.hashSize()
+	if err := decodeOIDs(idx, chunks[chunkIDOIDLookup], size); err != nil {
+		return nil, err
+	}
+
+	if err := decodeOffsets(idx, chunks[chunkIDObjectOffset], chunks[chunkIDLargeOffset]); err != nil {
+		return nil, err
+	}
+
+	objectCount := int(idx.Fanout[255])
+	if len(idx.OIDs)/size != objectCount || len(idx.PackIndex) != objectCount {
+		return nil, ErrMalformedMidxFile
+	}
+
+	trailer := make([]byte, size)
+	if _, err := d.r.Seek(int64(table[numChunks].offset), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(d.r, trailer); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	idx.Checksum = trailer
+
+	return idx, nil
+}
+
+// decodePackNames splits buf on NUL bytes into numPacks names. numPacks
+// comes straight from the untrusted MIDX header, so the result slice is
+// not preallocated to that capacity: a corrupt header claiming billions
+// of packs would otherwise force a multi-gigabyte allocation before buf,
+// which is already bounded by the file size, is ever consulted.
+func decodePackNames(idx *MemoryIndex, buf []byte, numPacks int) error {
+	var names []string
+	start := 0
+	for i := 0; i < len(buf) && len(names) < numPacks; i++ {
+		if buf[i] == 0 {
+			names = append(names, string(buf[start:i]))
+			start = i + 1
+		}
+	}
+
+	if len(names) != numPacks {
+		return ErrMalformedMidxFile
+	}
+
+	idx.PackNames = names
+	return nil
+}
+
+func decodeFanout(idx *MemoryIndex, buf []byte) error {
+	if len(buf) != 256*4 {
+		return ErrMalformedMidxFile
+	}
+
+	for i := 0; i < 256; i++ {
+		idx.Fanout[i] = binary.BigEndian.Uint32(buf[i*4 : i*4+4])
+	}
+
+	return nil
+}
+
+func decodeOIDs(idx *MemoryIndex, buf []byte, size int) error {
+	if size == 0 || len(buf)%size != 0 {
+		return ErrMalformedMidxFile
+	}
+
+	idx.OIDs = buf
+	return nil
+}
+
+func decodeOffsets(idx *MemoryIndex, ooff, loff []byte) error {
+	if len(ooff)%8 != 0 {
+		return ErrMalformedMidxFile
+	}
+
+	n := len(ooff) / 8
+	idx.PackIndex = make([]uint32, n)
+	idx.Offset = make([]uint64, n)
+
+	for i := 0; i < n; i++ {
+		entry := ooff[i*8 : i*8+8]
+		idx.PackIndex[i] = binary.BigEndian.Uint32(entry[0:4])
+		rawOffset := binary.BigEndian.Uint32(entry[4:8])
+
+		if rawOffset&largeOffsetFlag == 0 {
+			idx.Offset[i] = uint64(rawOffset)
+			continue
+		}
+
+		largeIndex := int(rawOffset &^ largeOffsetFlag)
+		if (largeIndex+1)*8 > len(loff) {
+			return ErrMalformedMidxFile
+		}
+
+		idx.Offset[i] = binary.BigEndian.Uint64(loff[largeIndex*8 : largeIndex*8+8])
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package midx_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	"github.com/grahambrooks/go-git/v5/plumbing/format/idxfile"
+	. "github.com/grahambrooks/go-git/v5/plumbing/format/midx"
+)
+
+// buildBenchPacks returns numPacks indexes of objectsPerPack objects each,
+// with distinct, sorted hashes so a MIDX over all of them is well formed.
+func buildBenchPacks(numPacks, objectsPerPack int) []PackIndex {
+	packs := make([]PackIndex, numPacks)
+	next := 0
+	for p := 0; p < numPacks; p++ {
+		w := &idxfile.Writer{}
+		for o := 0; o < objectsPerPack; o++ {
+			h := make([]byte, 20)
+			h[0] = byte(next >> 16)
+			h[1] = byte(next >> 8)
+			h[2] = byte(next)
+			w.Add(h, uint32(next), int64(next*100))
+			next++
+		}
+
+		idx, _ := w.Index()
+		packs[p] = PackIndex{PackName: fmt.Sprintf("pack-%03d.pack", p), Index: idx}
+	}
+
+	return packs
+}
+
+// BenchmarkLookupPerPack simulates the pre-MIDX lookup path: scanning each
+// pack's own .idx in turn until one contains the target hash.
+func BenchmarkLookupPerPack(b *testing.B) {
+	packs := buildBenchPacks(64, 200)
+
+	var target plumbing.Hash
+	target[0] = byte((len(packs) - 1) * 200 >> 16)
+	target[1] = byte((len(packs) - 1) * 200 >> 8)
+	target[2] = byte((len(packs) - 1) * 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range packs {
+			if ok, _ := p.Index.Contains(target); ok {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkLookupMidx looks up the same target hash via a combined MIDX.
+func BenchmarkLookupMidx(b *testing.B) {
+	packs := buildBenchPacks(64, 200)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := NewWriter(packs).Encode(buf); err != nil {
+		b.Fatal(err)
+	}
+
+	idx, err := NewDecoder(bytes.NewReader(buf.Bytes())).Decode()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var target plumbing.Hash
+	target[0] = byte((len(packs) - 1) * 200 >> 16)
+	target[1] = byte((len(packs) - 1) * 200 >> 8)
+	target[2] = byte((len(packs) - 1) * 200)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Contains(target)
+	}
+}
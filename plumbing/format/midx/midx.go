@@ -0,0 +1,189 @@
+// Package midx implements encoding and decoding of Git's multi-pack-index
+// (MIDX) files. A MIDX combines the fanout and name tables of many .idx
+// files into one, so an object lookup across a repository with many packs
+// costs one binary search instead of one per pack.
+package midx
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+)
+
+const (
+	// Magic is the 4-byte signature at the start of every MIDX file.
+	Magic = "MIDX"
+
+	// VersionSupported is the only MIDX format version understood by
+	// this package.
+	VersionSupported = 1
+
+	// HashVersionSHA1 identifies a MIDX built over SHA-1 object ids.
+	HashVersionSHA1 = 1
+	// HashVersionSHA256 identifies a MIDX built over SHA-256 object ids.
+	HashVersionSHA256 = 2
+
+	largeOffsetFlag = uint32(1) << 31
+)
+
+// Chunk IDs, as laid out in the MIDX chunk lookup table.
+var (
+	chunkIDPackNames    = [4]byte{'P', 'N', 'A', 'M'}
+	chunkIDOIDFanout    = [4]byte{'O', 'I', 'D', 'F'}
+	chunkIDOIDLookup    = [4]byte{'O', 'I', 'D', 'L'}
+	chunkIDObjectOffset = [4]byte{'O', 'O', 'F', 'F'}
+	chunkIDLargeOffset  = [4]byte{'L', 'O', 'F', 'F'}
+)
+
+func hashSize(hashVersion byte) int {
+	if hashVersion == HashVersionSHA256 {
+		return 32
+	}
+
+	return 20
+}
+
+// Entry is one object's record in a MIDX: its id, the pack that holds it,
+// and its offset within that pack.
+type Entry struct {
+	Hash       plumbing.Hash
+	PackName   string
+	PackOffset uint64
+}
+
+// MemoryIndex is the in-memory representation of a MIDX file.
+type MemoryIndex struct {
+	HashVersion byte
+
+	// PackNames are the packs covered by this MIDX, in the order they are
+	// indexed by PackIndex. Git requires this list to be sorted.
+	PackNames []string
+
+	// Fanout is the standard 256-entry cumulative-count fanout table over
+	// the first byte of each object id in OIDs.
+	Fanout [256]uint32
+
+	// OIDs holds every object id covered by this MIDX, sorted, each
+	// hashSize(HashVersion) bytes wide and concatenated.
+	OIDs []byte
+
+	// PackIndex and Offset are parallel to the sorted OIDs: PackIndex[i]
+	// is an index into PackNames, and Offset[i] the byte offset of that
+	// object within that pack.
+	PackIndex []uint32
+	Offset    []uint64
+
+	Checksum []byte
+}
+
+func (idx *MemoryIndex) hashSize() int {
+	return hashSize(idx.HashVersion)
+}
+
+// Count returns the number of objects covered by this MIDX.
+func (idx *MemoryIndex) Count() int {
+	return len(idx.PackIndex)
+}
+
+// find returns the position of the object id hash in the sorted OIDs
+// table, using the fanout table to narrow the binary search to a single
+// bucket. hash must be exactly hashSize(idx.HashVersion) bytes long.
+func (idx *MemoryIndex) find(hash []byte) (int, bool) {
+	size := idx.hashSize()
+	if size != len(hash) {
+		return 0, false
+	}
+
+	var low uint32
+	if hash[0] > 0 {
+		low = idx.Fanout[hash[0]-1]
+	}
+	high := idx.Fanout[hash[0]]
+
+	data := idx.OIDs
+
+	for low < high {
+		mid := (low + high) / 2
+		offset := int(mid) * size
+		cmp := bytes.Compare(data[offset:offset+size], hash)
+
+		switch {
+		case cmp < 0:
+			low = mid + 1
+		case cmp > 0:
+			high = mid
+		default:
+			return int(mid), true
+		}
+	}
+
+	return 0, false
+}
+
+// LookupOffsetBytes finds the pack and in-pack offset for the given raw
+// object id, at whatever width this MIDX's HashVersion uses. Unlike
+// LookupOffset, it works for both SHA-1 and SHA-256 MIDX files, since
+// plumbing.Hash cannot hold a SHA-256 id.
+func (idx *MemoryIndex) LookupOffsetBytes(hash []byte) (packName string, offset uint64, ok bool) {
+	i, found := idx.find(hash)
+	if !found {
+		return "", 0, false
+	}
+
+	return idx.PackNames[idx.PackIndex[i]], idx.Offset[i], true
+}
+
+// LookupOffset finds the pack and in-pack offset for the given object
+// hash. ok is false if the object is not covered by this MIDX. It only
+// supports SHA-1 MIDX files; use LookupOffsetBytes for a SHA-256 MIDX.
+func (idx *MemoryIndex) LookupOffset(h plumbing.Hash) (packName string, offset uint64, ok bool) {
+	return idx.LookupOffsetBytes(h[:])
+}
+
+// ContainsBytes reports whether the given raw object id, at whatever
+// width this MIDX's HashVersion uses, is covered by this MIDX.
+func (idx *MemoryIndex) ContainsBytes(hash []byte) bool {
+	_, ok := idx.find(hash)
+	return ok
+}
+
+// Contains reports whether the given object hash is covered by this MIDX.
+// It only supports SHA-1 MIDX files; use ContainsBytes for a SHA-256 MIDX.
+func (idx *MemoryIndex) Contains(h plumbing.Hash) bool {
+	return idx.ContainsBytes(h[:])
+}
+
+// Iter returns an iterator over every entry in this MIDX, in sorted-hash
+// order.
+func (idx *MemoryIndex) Iter() *EntryIter {
+	return &EntryIter{idx: idx}
+}
+
+// EntryIter iterates the entries of a MemoryIndex in sorted-hash order.
+type EntryIter struct {
+	idx *MemoryIndex
+	pos int
+}
+
+// Next returns the next entry, or io.EOF once the iterator is exhausted.
+func (it *EntryIter) Next() (*Entry, error) {
+	if it.idx == nil || it.pos >= len(it.idx.PackIndex) {
+		return nil, io.EOF
+	}
+
+	size := it.idx.hashSize()
+	offset := it.pos * size
+
+	var h plumbing.Hash
+	copy(h[:], it.idx.OIDs[offset:offset+size])
+
+	e := &Entry{
+		Hash:       h,
+		PackName:   it.idx.PackNames[it.idx.PackIndex[it.pos]],
+		PackOffset: it.idx.Offset[it.pos],
+	}
+	it.pos++
+
+	return e, nil
+}
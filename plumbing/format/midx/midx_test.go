@@ -0,0 +1,98 @@
+package midx_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	"github.com/grahambrooks/go-git/v5/plumbing/format/idxfile"
+	. "github.com/grahambrooks/go-git/v5/plumbing/format/midx"
+)
+
+type MidxSuite struct {
+	suite.Suite
+}
+
+func TestMidxSuite(t *testing.T) {
+	suite.Run(t, new(MidxSuite))
+}
+
+func (s *MidxSuite) TestWriteDecodeLookup() {
+	packA := &idxfile.Writer{}
+	packA.Add(hashBytes(0x01), 1, 100)
+	packA.Add(hashBytes(0x03), 2, 200)
+	idxA, err := packA.Index()
+	s.NoError(err)
+
+	packB := &idxfile.Writer{}
+	packB.Add(hashBytes(0x02), 3, 300)
+	idxB, err := packB.Index()
+	s.NoError(err)
+
+	w := NewWriter([]PackIndex{
+		{PackName: "pack-b.pack", Index: idxB},
+		{PackName: "pack-a.pack", Index: idxA},
+	})
+
+	buf := bytes.NewBuffer(nil)
+	_, err = w.Encode(buf)
+	s.NoError(err)
+
+	idx, err := NewDecoder(bytes.NewReader(buf.Bytes())).Decode()
+	s.NoError(err)
+	s.Equal([]string{"pack-a.pack", "pack-b.pack"}, idx.PackNames)
+	s.Equal(3, idx.Count())
+
+	name, offset, ok := idx.LookupOffset(hashBytesToHash(0x01))
+	s.True(ok)
+	s.Equal("pack-a.pack", name)
+	s.Equal(uint64(100), offset)
+
+	name, offset, ok = idx.LookupOffset(hashBytesToHash(0x02))
+	s.True(ok)
+	s.Equal("pack-b.pack", name)
+	s.Equal(uint64(300), offset)
+
+	_, _, ok = idx.LookupOffset(hashBytesToHash(0xff))
+	s.False(ok)
+}
+
+// TestDecodeRejectsHugeNumPacksWithoutHugeAllocation corrupts the numPacks
+// header field to a value far larger than the PNAM chunk could possibly
+// hold, so a decoder that sized its pack-name slice off that field
+// directly would attempt a multi-gigabyte allocation before ever reading
+// PNAM. Decode must instead reject it as malformed once the PNAM chunk
+// comes up short, without crashing or hanging in the process.
+func (s *MidxSuite) TestDecodeRejectsHugeNumPacksWithoutHugeAllocation() {
+	packA := &idxfile.Writer{}
+	packA.Add(hashBytes(0x01), 1, 100)
+	idxA, err := packA.Index()
+	s.NoError(err)
+
+	w := NewWriter([]PackIndex{{PackName: "pack-a.pack", Index: idxA}})
+
+	buf := bytes.NewBuffer(nil)
+	_, err = w.Encode(buf)
+	s.NoError(err)
+
+	raw := buf.Bytes()
+	binary.BigEndian.PutUint32(raw[8:12], 0xffffffff)
+
+	_, err = NewDecoder(bytes.NewReader(raw)).Decode()
+	s.ErrorIs(err, ErrMalformedMidxFile)
+}
+
+func hashBytes(b byte) []byte {
+	h := make([]byte, 20)
+	h[0] = b
+	return h
+}
+
+func hashBytesToHash(b byte) plumbing.Hash {
+	var h plumbing.Hash
+	h[0] = b
+	return h
+}
@@ -0,0 +1,79 @@
+package midx
+
+import (
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	"github.com/grahambrooks/go-git/v5/plumbing/storer"
+)
+
+// PackLookup resolves an object hash to the pack and offset that holds
+// it. MemoryIndex satisfies this via LookupOffset, so a per-pack .idx
+// lookup can be used as the Fallback of an ObjectIndex with the same
+// shape.
+type PackLookup interface {
+	LookupOffset(h plumbing.Hash) (packName string, offset uint64, ok bool)
+}
+
+// ObjectIndex resolves an object to its pack and offset, preferring a
+// single combined MIDX fanout over scanning every pack's own .idx, and
+// falling back to Fallback for anything the MIDX doesn't cover: a nil
+// Midx, or an object added to a pack since the MIDX was last written
+// (i.e. the MIDX is stale).
+type ObjectIndex struct {
+	Midx     *MemoryIndex
+	Fallback PackLookup
+}
+
+// LookupOffset implements PackLookup.
+func (o *ObjectIndex) LookupOffset(h plumbing.Hash) (packName string, offset uint64, ok bool) {
+	if o.Midx != nil {
+		if packName, offset, ok := o.Midx.LookupOffset(h); ok {
+			return packName, offset, true
+		}
+	}
+
+	if o.Fallback != nil {
+		return o.Fallback.LookupOffset(h)
+	}
+
+	return "", 0, false
+}
+
+// MidxObjectStorage is a storer.EncodedObjectStorer that consults an
+// ObjectIndex to accelerate HasEncodedObject: a hit answers "present"
+// without opening any pack, but a miss is never treated as not-found on
+// its own, since it only means the object isn't covered by the MIDX (or
+// Fallback) — it may still be a loose object, or live in a pack added
+// after the MIDX was last written. A miss is always handed to the
+// embedded storer, which remains the only source of truth for "does
+// this repository have this object at all." EncodedObject itself is not
+// overridden: the embedded storer is the only thing that can decode
+// packfile content, so Index has nothing to add there beyond what
+// HasEncodedObject already accelerates.
+type MidxObjectStorage struct {
+	storer.EncodedObjectStorer
+	Index *ObjectIndex
+}
+
+// NewMidxObjectStorage wraps base, preferring midx for object lookups
+// and falling back to fallback (typically base's own per-pack .idx
+// lookup) when midx is nil or does not cover a requested object; any
+// object neither covers is still answered by base, never treated as
+// not-found by this wrapper alone.
+func NewMidxObjectStorage(base storer.EncodedObjectStorer, midx *MemoryIndex, fallback PackLookup) *MidxObjectStorage {
+	return &MidxObjectStorage{
+		EncodedObjectStorer: base,
+		Index:               &ObjectIndex{Midx: midx, Fallback: fallback},
+	}
+}
+
+// HasEncodedObject overrides the embedded storer, answering "present"
+// straight from Index when it covers h, and delegating to the embedded
+// storer otherwise — a miss in Index only means it's uncovered, not
+// absent.
+func (s *MidxObjectStorage) HasEncodedObject(h plumbing.Hash) error {
+	if _, _, ok := s.Index.LookupOffset(h); ok {
+		return nil
+	}
+
+	return s.EncodedObjectStorer.HasEncodedObject(h)
+}
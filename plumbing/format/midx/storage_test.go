@@ -0,0 +1,112 @@
+package midx_test
+
+import (
+	"testing"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	"github.com/grahambrooks/go-git/v5/plumbing/storer"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/grahambrooks/go-git/v5/plumbing/format/midx"
+)
+
+func TestStorageSuite(t *testing.T) {
+	suite.Run(t, new(StorageSuite))
+}
+
+type StorageSuite struct {
+	suite.Suite
+}
+
+// fakeStorer is a minimal storer.EncodedObjectStorer that records which
+// hashes EncodedObject and HasEncodedObject were called with, so a test
+// can tell whether MidxObjectStorage short-circuited a lookup instead of
+// delegating it.
+type fakeStorer struct {
+	reads     []plumbing.Hash
+	hasChecks []plumbing.Hash
+}
+
+func (f *fakeStorer) NewEncodedObject() plumbing.EncodedObject { return nil }
+
+func (f *fakeStorer) SetEncodedObject(o plumbing.EncodedObject) (plumbing.Hash, error) {
+	return plumbing.ZeroHash, nil
+}
+
+func (f *fakeStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	f.reads = append(f.reads, h)
+	return nil, nil
+}
+
+func (f *fakeStorer) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	return nil, nil
+}
+
+func (f *fakeStorer) HasEncodedObject(h plumbing.Hash) error {
+	f.hasChecks = append(f.hasChecks, h)
+	return nil
+}
+
+func (f *fakeStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) { return 0, nil }
+
+func (s *StorageSuite) TestEncodedObjectAlwaysDelegates() {
+	base := &fakeStorer{}
+	ms := NewMidxObjectStorage(base, &MemoryIndex{HashVersion: HashVersionSHA1}, nil)
+
+	target := plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")
+	_, err := ms.EncodedObject(0, target)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{target}, base.reads, "a miss in Index is not grounds to short-circuit EncodedObject")
+}
+
+func (s *StorageSuite) TestHasEncodedObjectShortCircuitsOnHit() {
+	base := &fakeStorer{}
+	idx := &MemoryIndex{
+		HashVersion: HashVersionSHA1,
+		PackNames:   []string{"pack-000.pack"},
+		OIDs:        make([]byte, 20),
+		PackIndex:   []uint32{0},
+		Offset:      []uint64{42},
+	}
+	for k := range idx.Fanout {
+		idx.Fanout[k] = 1
+	}
+
+	ms := NewMidxObjectStorage(base, idx, nil)
+
+	var zero plumbing.Hash
+	s.NoError(ms.HasEncodedObject(zero))
+	s.Empty(base.hasChecks, "a hit in Index must not fall through to the embedded storer")
+}
+
+func (s *StorageSuite) TestHasEncodedObjectFallsThroughOnMiss() {
+	base := &fakeStorer{}
+	target := plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")
+	ms := NewMidxObjectStorage(base, &MemoryIndex{HashVersion: HashVersionSHA1}, nil)
+
+	s.NoError(ms.HasEncodedObject(target))
+	s.Equal([]plumbing.Hash{target}, base.hasChecks, "a miss in Index only means uncovered, so it must fall through")
+}
+
+func (s *StorageSuite) TestFallbackUsedWhenMidxNil() {
+	base := &fakeStorer{}
+	target := plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")
+	fallback := fakePackLookup{target: target}
+
+	ms := NewMidxObjectStorage(base, nil, fallback)
+
+	s.NoError(ms.HasEncodedObject(target))
+	s.Empty(base.hasChecks, "the fallback PackLookup covers target, so the embedded storer must not be consulted")
+}
+
+type fakePackLookup struct {
+	target plumbing.Hash
+}
+
+func (f fakePackLookup) LookupOffset(h plumbing.Hash) (string, uint64, bool) {
+	if h == f.target {
+		return "pack-000.pack", 7, true
+	}
+
+	return "", 0, false
+}
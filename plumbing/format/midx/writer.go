@@ -0,0 +1,262 @@
+package midx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/grahambrooks/go-git/v5/plumbing/format/idxfile"
+)
+
+// PackIndex pairs a pack's on-disk file name with its decoded .idx, as
+// Writer needs both to build a MIDX entry.
+type PackIndex struct {
+	PackName string
+	Index    *idxfile.MemoryIndex
+}
+
+// Writer builds a MIDX file out of a set of packs and their indexes.
+type Writer struct {
+	packs []PackIndex
+}
+
+// NewWriter builds a Writer over the given packs. Every pack must share
+// the same object hash algorithm.
+func NewWriter(packs []PackIndex) *Writer {
+	return &Writer{packs: packs}
+}
+
+type writerEntry struct {
+	hash       []byte
+	packIndex  uint32
+	packOffset uint64
+}
+
+// Encode writes the MIDX file for w's packs to out, returning the number
+// of bytes written.
+func (w *Writer) Encode(out io.Writer) (int, error) {
+	if len(w.packs) == 0 {
+		return 0, fmt.Errorf("midx: no packs to index")
+	}
+
+	names := make([]string, len(w.packs))
+	for i, p := range w.packs {
+		names[i] = p.PackName
+	}
+
+	sort.Strings(names)
+	packPos := make(map[string]uint32, len(names))
+	for i, n := range names {
+		packPos[n] = uint32(i)
+	}
+
+	hashVersion := byte(HashVersionSHA1)
+	if w.packs[0].Index.HashAlgorithm == idxfile.HashAlgorithmSHA256 {
+		hashVersion = HashVersionSHA256
+	}
+	size := hashSize(hashVersion)
+
+	var entries []writerEntry
+	for _, p := range w.packs {
+		iter, err := p.Index.Entries()
+		if err != nil {
+			return 0, err
+		}
+
+		for {
+			e, err := iter.Next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return 0, err
+			}
+
+			if len(e.RawHash) != size {
+				return 0, fmt.Errorf("midx: object hash width %d does not match expected %d", len(e.RawHash), size)
+			}
+
+			entries = append(entries, writerEntry{
+				hash:       append([]byte{}, e.RawHash...),
+				packIndex:  packPos[p.PackName],
+				packOffset: e.Offset,
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].hash, entries[j].hash) < 0
+	})
+
+	pnam := encodePackNames(names)
+	oidf := encodeFanout(entries)
+	oidl := encodeOIDs(entries)
+	ooff, loff := encodeOffsets(entries)
+
+	chunks := []struct {
+		id   [4]byte
+		data []byte
+	}{
+		{chunkIDPackNames, pnam},
+		{chunkIDOIDFanout, oidf},
+		{chunkIDOIDLookup, oidl},
+		{chunkIDObjectOffset, ooff},
+	}
+	if len(loff) > 0 {
+		chunks = append(chunks, struct {
+			id   [4]byte
+			data []byte
+		}{chunkIDLargeOffset, loff})
+	}
+
+	written := 0
+
+	n, err := out.Write([]byte(Magic))
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	header := []byte{byte(VersionSupported), hashVersion, byte(len(chunks)), 0}
+	n, err = out.Write(header)
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	n, err = writeUint32(out, uint32(len(w.packs)))
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	tableSize := (len(chunks) + 1) * 12
+	headerSize := 12
+	offset := uint64(headerSize + tableSize)
+
+	for _, c := range chunks {
+		n, err := out.Write(c.id[:])
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		n, err = writeUint64(out, offset)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		offset += uint64(len(c.data))
+	}
+
+	// Terminating chunk-table entry: zero id, offset marking the start of
+	// the trailing checksum.
+	n, err = out.Write(make([]byte, 4))
+	written += n
+	if err != nil {
+		return written, err
+	}
+	n, err = writeUint64(out, offset)
+	written += n
+	if err != nil {
+		return written, err
+	}
+
+	for _, c := range chunks {
+		n, err := out.Write(c.data)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	checksum := make([]byte, size)
+	n, err = out.Write(checksum)
+	written += n
+
+	return written, err
+}
+
+func encodePackNames(names []string) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, n := range names {
+		buf.WriteString(n)
+		buf.WriteByte(0)
+	}
+
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeFanout(entries []writerEntry) []byte {
+	var fanout [256]uint32
+	for _, e := range entries {
+		fanout[e.hash[0]]++
+	}
+
+	var running uint32
+	for i := range fanout {
+		running += fanout[i]
+		fanout[i] = running
+	}
+
+	buf := make([]byte, 256*4)
+	for i, f := range fanout {
+		binary.BigEndian.PutUint32(buf[i*4:i*4+4], f)
+	}
+
+	return buf
+}
+
+func encodeOIDs(entries []writerEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, e := range entries {
+		buf.Write(e.hash)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeOffsets(entries []writerEntry) (ooff, loff []byte) {
+	ooffBuf := bytes.NewBuffer(nil)
+	loffBuf := bytes.NewBuffer(nil)
+
+	for _, e := range entries {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint32(b[0:4], e.packIndex)
+
+		if e.packOffset <= 0x7fffffff {
+			binary.BigEndian.PutUint32(b[4:8], uint32(e.packOffset))
+		} else {
+			largeIndex := uint32(loffBuf.Len() / 8)
+			binary.BigEndian.PutUint32(b[4:8], largeOffsetFlag|largeIndex)
+
+			lb := make([]byte, 8)
+			binary.BigEndian.PutUint64(lb, e.packOffset)
+			loffBuf.Write(lb)
+		}
+
+		ooffBuf.Write(b)
+	}
+
+	return ooffBuf.Bytes(), loffBuf.Bytes()
+}
+
+func writeUint32(w io.Writer, v uint32) (int, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return w.Write(b)
+}
+
+func writeUint64(w io.Writer, v uint64) (int, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return w.Write(b)
+}
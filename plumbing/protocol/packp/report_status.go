@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/grahambrooks/go-git/v5/plumbing"
@@ -13,13 +14,57 @@ import (
 
 const (
 	ok = "ok"
+
+	optionOldOID       = "old-oid"
+	optionNewOID       = "new-oid"
+	optionRef          = "ref"
+	optionForcedUpdate = "forced-update"
+
+	// CapReportStatus is the capability a receive-pack server advertises
+	// to say it will send a version 1 ReportStatus after unpacking.
+	CapReportStatus = "report-status"
+	// CapReportStatusV2 is the capability a receive-pack server advertises
+	// to say it will send a version 2 ReportStatus, with per-command
+	// option lines, after unpacking.
+	CapReportStatusV2 = "report-status-v2"
 )
 
+// NegotiateReportStatusVersion picks the ReportStatus.Version a
+// receive-pack client should expect from the server, given the
+// capabilities it advertised: 2 if the server advertised
+// report-status-v2, 1 if it only advertised report-status, and 0 if it
+// advertised neither, meaning the server will send no report status at
+// all.
+func NegotiateReportStatusVersion(serverCapabilities []string) int {
+	for _, c := range serverCapabilities {
+		if c == CapReportStatusV2 {
+			return 2
+		}
+	}
+
+	for _, c := range serverCapabilities {
+		if c == CapReportStatus {
+			return 1
+		}
+	}
+
+	return 0
+}
+
 // ReportStatus is a report status message, as used in the git-receive-pack
-// process whenever the 'report-status' capability is negotiated.
+// process whenever the 'report-status' or 'report-status-v2' capability is
+// negotiated.
 type ReportStatus struct {
 	UnpackStatus    string
 	CommandStatuses []*CommandStatus
+
+	// Version selects the wire format written by Encode. 1 is the original
+	// report-status format; 2 is report-status-v2, in which each command
+	// status may be followed by one or more "option" lines carrying
+	// additional metadata about the update. The zero value behaves as
+	// version 1. Decode accepts option lines whatever Version is, and does
+	// not set Version.
+	Version int
 }
 
 // NewReportStatus creates a new ReportStatus message.
@@ -49,7 +94,7 @@ func (s *ReportStatus) Encode(w io.Writer) error {
 	}
 
 	for _, cs := range s.CommandStatuses {
-		if err := cs.encode(w); err != nil {
+		if err := cs.encode(w, s.Version); err != nil {
 			return err
 		}
 	}
@@ -82,6 +127,13 @@ func (s *ReportStatus) Decode(r io.Reader) error {
 			break
 		}
 
+		if bytes.HasPrefix(bytes.TrimSuffix(b, eol), []byte("option ")) {
+			if err := s.decodeOption(b); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if err := s.decodeCommandStatus(b); err != nil {
 			return err
 		}
@@ -147,11 +199,72 @@ func (s *ReportStatus) decodeCommandStatus(b []byte) error {
 	return nil
 }
 
+// decodeOption attaches an "option" line, as used by report-status-v2, to
+// the most recently decoded CommandStatus.
+func (s *ReportStatus) decodeOption(b []byte) error {
+	if len(s.CommandStatuses) == 0 {
+		return fmt.Errorf("option line with no preceding command status")
+	}
+
+	b = bytes.TrimSuffix(b, eol)
+
+	fields := strings.SplitN(string(b), " ", 3)
+	if len(fields) < 2 || fields[0] != "option" {
+		return fmt.Errorf("malformed option line: %s", string(b))
+	}
+
+	key := fields[1]
+	value := ""
+	if len(fields) == 3 {
+		value = fields[2]
+	}
+
+	cs := s.CommandStatuses[len(s.CommandStatuses)-1]
+	if cs.Options == nil {
+		cs.Options = make(map[string]string)
+	}
+	cs.Options[key] = value
+
+	switch key {
+	case optionOldOID:
+		cs.OldOID = plumbing.NewHash(value)
+	case optionNewOID:
+		cs.NewOID = plumbing.NewHash(value)
+	case optionRef:
+		cs.RewrittenRef = plumbing.ReferenceName(value)
+	case optionForcedUpdate:
+		cs.ForcedUpdate = true
+	}
+
+	return nil
+}
+
 // CommandStatus is the status of a reference in a report status.
 // See ReportStatus struct.
 type CommandStatus struct {
 	ReferenceName plumbing.ReferenceName
 	Status        string
+
+	// Options carries the raw option lines reported for this reference
+	// under report-status-v2, keyed by option name. It is populated
+	// alongside the typed fields below, which expose the options Git
+	// defines today.
+	Options map[string]string
+
+	// OldOID and NewOID report the object ids the update moved the
+	// reference from and to, as advertised via the report-status-v2
+	// "old-oid" and "new-oid" options.
+	OldOID plumbing.Hash
+	NewOID plumbing.Hash
+
+	// RewrittenRef reports the reference actually updated, when a
+	// server-side hook rewrites the target of a push, via the
+	// report-status-v2 "ref" option.
+	RewrittenRef plumbing.ReferenceName
+
+	// ForcedUpdate reports whether the update was a forced, non-fast-forward
+	// update, via the report-status-v2 "forced-update" option.
+	ForcedUpdate bool
 }
 
 // Error returns the error, if any.
@@ -164,12 +277,96 @@ func (s *CommandStatus) Error() error {
 		s.ReferenceName.String(), s.Status)
 }
 
-func (s *CommandStatus) encode(w io.Writer) error {
+func (s *CommandStatus) encode(w io.Writer, version int) error {
 	if s.Error() == nil {
-		_, err := pktline.Writef(w, "ok %s\n", s.ReferenceName.String())
-		return err
+		if _, err := pktline.Writef(w, "ok %s\n", s.ReferenceName.String()); err != nil {
+			return err
+		}
+	} else {
+		if _, err := pktline.Writef(w, "ng %s %s\n", s.ReferenceName.String(), s.Status); err != nil {
+			return err
+		}
 	}
 
-	_, err := pktline.Writef(w, "ng %s %s\n", s.ReferenceName.String(), s.Status)
+	if version < 2 {
+		return nil
+	}
+
+	return s.encodeOptions(w)
+}
+
+// encodeOptions writes the report-status-v2 option lines following this
+// command status. Options, kept in sync with the typed fields by
+// decodeOption, is the source of truth for which options were actually
+// set: checking the typed fields themselves instead (e.g. "OldOID is
+// non-zero") can't tell a legitimate all-zero old-oid, which Git itself
+// uses to report a ref that did not exist before this update, apart from
+// a field that was simply never set. A CommandStatus built by hand with
+// only the typed fields (and no Options entry) falls back to the old
+// IsZero/empty checks, so direct construction for encoding still works;
+// it just can't represent an intentional all-zero old-oid or new-oid
+// that way. Any option left in Options that isn't one of the four known
+// keys is written last, sorted by key, so the encoded bytes are
+// deterministic regardless of Go's randomized map iteration order.
+func (s *CommandStatus) encodeOptions(w io.Writer) error {
+	written := make(map[string]bool)
+
+	if v, ok := s.Options[optionOldOID]; ok {
+		if err := s.writeOption(w, optionOldOID, v); err != nil {
+			return err
+		}
+		written[optionOldOID] = true
+	} else if !s.OldOID.IsZero() {
+		if err := s.writeOption(w, optionOldOID, s.OldOID.String()); err != nil {
+			return err
+		}
+		written[optionOldOID] = true
+	}
+
+	if v, ok := s.Options[optionNewOID]; ok {
+		if err := s.writeOption(w, optionNewOID, v); err != nil {
+			return err
+		}
+		written[optionNewOID] = true
+	} else if !s.NewOID.IsZero() {
+		if err := s.writeOption(w, optionNewOID, s.NewOID.String()); err != nil {
+			return err
+		}
+		written[optionNewOID] = true
+	}
+
+	if s.RewrittenRef != "" {
+		if err := s.writeOption(w, optionRef, s.RewrittenRef.String()); err != nil {
+			return err
+		}
+		written[optionRef] = true
+	}
+
+	if s.ForcedUpdate {
+		if _, err := pktline.Writef(w, "option %s\n", optionForcedUpdate); err != nil {
+			return err
+		}
+		written[optionForcedUpdate] = true
+	}
+
+	leftover := make([]string, 0, len(s.Options))
+	for key := range s.Options {
+		if !written[key] {
+			leftover = append(leftover, key)
+		}
+	}
+	sort.Strings(leftover)
+
+	for _, key := range leftover {
+		if err := s.writeOption(w, key, s.Options[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *CommandStatus) writeOption(w io.Writer, key, value string) error {
+	_, err := pktline.Writef(w, "option %s %s\n", key, value)
 	return err
 }
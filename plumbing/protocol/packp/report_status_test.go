@@ -0,0 +1,144 @@
+package packp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grahambrooks/go-git/v5/plumbing"
+	"github.com/grahambrooks/go-git/v5/plumbing/format/pktline"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReportStatusSuite struct {
+	suite.Suite
+}
+
+func TestReportStatusSuite(t *testing.T) {
+	suite.Run(t, new(ReportStatusSuite))
+}
+
+func (s *ReportStatusSuite) TestEncodeDecodeV1() {
+	rs := NewReportStatus()
+	rs.UnpackStatus = ok
+	rs.CommandStatuses = []*CommandStatus{
+		{ReferenceName: "refs/heads/master", Status: ok},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	s.NoError(rs.Encode(buf))
+
+	decoded := NewReportStatus()
+	s.NoError(decoded.Decode(buf))
+	s.Equal(rs.UnpackStatus, decoded.UnpackStatus)
+	s.Len(decoded.CommandStatuses, 1)
+	s.Equal(plumbing.ReferenceName("refs/heads/master"), decoded.CommandStatuses[0].ReferenceName)
+	s.Nil(decoded.CommandStatuses[0].Options)
+}
+
+func (s *ReportStatusSuite) TestEncodeDecodeV2() {
+	oldOID := plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d")
+	newOID := plumbing.NewHash("aab686eafeb1f44702738c8b0f24f2567c36da6d")
+
+	rs := NewReportStatus()
+	rs.Version = 2
+	rs.UnpackStatus = ok
+	rs.CommandStatuses = []*CommandStatus{
+		{
+			ReferenceName: "refs/heads/master",
+			Status:        ok,
+			OldOID:        oldOID,
+			NewOID:        newOID,
+			ForcedUpdate:  true,
+			RewrittenRef:  "refs/heads/renamed",
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	s.NoError(rs.Encode(buf))
+
+	decoded := NewReportStatus()
+	s.NoError(decoded.Decode(buf))
+	s.Len(decoded.CommandStatuses, 1)
+
+	cs := decoded.CommandStatuses[0]
+	s.Equal(oldOID, cs.OldOID)
+	s.Equal(newOID, cs.NewOID)
+	s.True(cs.ForcedUpdate)
+	s.Equal(plumbing.ReferenceName("refs/heads/renamed"), cs.RewrittenRef)
+	s.Equal(oldOID.String(), cs.Options["old-oid"])
+}
+
+func (s *ReportStatusSuite) TestEncodeDecodeV2RoundTripsZeroOldOID() {
+	newOID := plumbing.NewHash("aab686eafeb1f44702738c8b0f24f2567c36da6d")
+
+	rs := NewReportStatus()
+	rs.Version = 2
+	rs.UnpackStatus = ok
+	rs.CommandStatuses = []*CommandStatus{
+		{
+			ReferenceName: "refs/heads/new-branch",
+			Status:        ok,
+			OldOID:        plumbing.ZeroHash,
+			NewOID:        newOID,
+			Options:       map[string]string{"old-oid": plumbing.ZeroHash.String()},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	s.NoError(rs.Encode(buf))
+	s.Contains(buf.String(), "option old-oid "+plumbing.ZeroHash.String())
+
+	decoded := NewReportStatus()
+	s.NoError(decoded.Decode(buf))
+	s.Len(decoded.CommandStatuses, 1)
+
+	cs := decoded.CommandStatuses[0]
+	s.Equal(plumbing.ZeroHash, cs.OldOID, "an all-zero old-oid means the ref did not exist before this update, and must not be dropped as if unset")
+	s.Equal(newOID, cs.NewOID)
+}
+
+func (s *ReportStatusSuite) TestEncodeUnknownOptionsDeterministicOrder() {
+	rs := NewReportStatus()
+	rs.Version = 2
+	rs.UnpackStatus = ok
+	cs := &CommandStatus{
+		ReferenceName: "refs/heads/master",
+		Status:        ok,
+		Options: map[string]string{
+			"z-option": "z",
+			"a-option": "a",
+			"m-option": "m",
+		},
+	}
+	rs.CommandStatuses = []*CommandStatus{cs}
+
+	var encoded string
+	for i := 0; i < 10; i++ {
+		buf := bytes.NewBuffer(nil)
+		s.NoError(rs.Encode(buf))
+		if i == 0 {
+			encoded = buf.String()
+			continue
+		}
+		s.Equal(encoded, buf.String(), "unknown option order must not depend on map iteration order")
+	}
+
+	wantOrder := "0016option a-option a\n0016option m-option m\n0016option z-option z\n"
+	s.Contains(encoded, wantOrder)
+}
+
+func (s *ReportStatusSuite) TestNegotiateReportStatusVersion() {
+	s.Equal(2, NegotiateReportStatusVersion([]string{"side-band-64k", CapReportStatusV2}))
+	s.Equal(1, NegotiateReportStatusVersion([]string{CapReportStatus}))
+	s.Equal(0, NegotiateReportStatusVersion([]string{"side-band-64k"}))
+}
+
+func (s *ReportStatusSuite) TestDecodeOptionWithoutCommandStatus() {
+	raw := bytes.NewBufferString("")
+	s.NoError(pktline.WriteString(raw, "unpack ok\n"))
+	s.NoError(pktline.WriteString(raw, "option old-oid 8ab686eafeb1f44702738c8b0f24f2567c36da6d\n"))
+	s.NoError(pktline.WriteFlush(raw))
+
+	rs := NewReportStatus()
+	s.Error(rs.Decode(raw))
+}
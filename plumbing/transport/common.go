@@ -0,0 +1,15 @@
+// Package transport includes the implementation for different transport
+// protocols.
+package transport
+
+import "github.com/grahambrooks/go-git/v5/plumbing/protocol/packp"
+
+// PushResult is the result of a push request.
+type PushResult struct {
+	// ReportStatus is the report-status (or report-status-v2) sent back
+	// by the server once it has unpacked and updated references. It is
+	// nil if the server advertised neither capability, in which case a
+	// push's success can only be inferred from the absence of a
+	// transport error; see packp.NegotiateReportStatusVersion.
+	ReportStatus *packp.ReportStatus
+}